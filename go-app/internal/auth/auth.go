@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionUserKey はセッションに保存されるユーザーIDのキー
+const SessionUserKey = "user_id"
+
+// ErrUserExists はユーザー名が既に登録済みの場合に返される
+var ErrUserExists = errors.New("auth: username already registered")
+
+// ErrInvalidCredentials はユーザー名またはパスワードが一致しない場合に返される
+var ErrInvalidCredentials = errors.New("auth: invalid username or password")
+
+// Account は登録済みアカウントを表す
+type Account struct {
+	UserID       string
+	Username     string
+	PasswordHash []byte
+}
+
+// Store はアカウントの永続化を担う。今はプロセス内メモリ実装のみ提供するが、
+// 将来 Python API 側のユーザーテーブルに差し替えられるようインターフェース化している。
+type Store interface {
+	Create(username, password string) (*Account, error)
+	Authenticate(username, password string) (*Account, error)
+}
+
+// MemoryStore はプロセス内メモリにアカウントを保持する Store 実装
+type MemoryStore struct {
+	mu     sync.RWMutex
+	byName map[string]*Account
+	nextID int
+}
+
+// NewMemoryStore は空の MemoryStore を生成する
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{byName: make(map[string]*Account)}
+}
+
+// Create は新しいアカウントを登録する。ユーザー名が既に存在する場合は ErrUserExists を返す
+func (s *MemoryStore) Create(username, password string) (*Account, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, errors.New("auth: username and password are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byName[username]; exists {
+		return nil, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	s.nextID++
+	account := &Account{
+		UserID:       userIDFromSeq(s.nextID),
+		Username:     username,
+		PasswordHash: hash,
+	}
+	s.byName[username] = account
+	return account, nil
+}
+
+// Authenticate はユーザー名とパスワードを検証し、一致すればアカウントを返す
+func (s *MemoryStore) Authenticate(username, password string) (*Account, error) {
+	s.mu.RLock()
+	account, ok := s.byName[username]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(account.PasswordHash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return account, nil
+}
+
+func userIDFromSeq(seq int) string {
+	return "u" + itoa(seq)
+}
+
+// itoa はちょっとした依存追加を避けるための最小限の整数→文字列変換
+func itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	digits := make([]byte, 0, 8)
+	for n > 0 {
+		digits = append([]byte{byte('0' + n%10)}, digits...)
+		n /= 10
+	}
+	return string(digits)
+}
+
+// Handler はアカウント関連のルートを束ねる
+type Handler struct {
+	store Store
+}
+
+// NewHandler は Store を受け取って Handler を生成する
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+type credentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Register は新規アカウントを作成する
+func (h *Handler) Register(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	account, err := h.store.Create(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(SessionUserKey, account.UserID)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"user_id": account.UserID, "username": account.Username})
+}
+
+// Login はユーザー名とパスワードを検証し、成功すればセッションを開始する
+func (h *Handler) Login(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	account, err := h.store.Authenticate(req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(SessionUserKey, account.UserID)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": account.UserID, "username": account.Username})
+}
+
+// Logout は現在のセッションを破棄する
+func (h *Handler) Logout(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Options(sessions.Options{MaxAge: -1})
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// RequireAuth はセッションに有効な user_id が無いリクエストを 401 で拒否するミドルウェア。
+// 成功した場合は下流のハンドラが c.GetString(SessionUserKey) で user_id を取得できるようにする。
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		userID, ok := session.Get(SessionUserKey).(string)
+		if !ok || userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		c.Set(SessionUserKey, userID)
+		c.Next()
+	}
+}
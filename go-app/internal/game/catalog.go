@@ -0,0 +1,51 @@
+// Package game implements the upgrade purchase logic that used to be a stub
+// forwarded to the Python API. Cost curve and state mutation now live here so
+// they can be unit tested without a running Python process.
+package game
+
+import "math"
+
+// UpgradeDef は購入可能なアップグレードの定義
+type UpgradeDef struct {
+	ID          string
+	Name        string
+	Description string
+	BaseCost    int64
+	CPSBoost    int64
+	ClickBoost  int
+}
+
+// baseClickPower はアップグレードを何も持っていない状態のクリックパワー
+const baseClickPower = 1
+
+// Catalog は購入可能な全アップグレードの定義。sendDefaultGameData が持っていた
+// ハードコードの一覧と一致させている
+var Catalog = []UpgradeDef{
+	{ID: "cursor", Name: "Cursor", Description: "Clicks cookies for you", BaseCost: 15, CPSBoost: 1, ClickBoost: 0},
+	{ID: "grandma", Name: "Grandma", Description: "A nice grandma to bake more cookies", BaseCost: 100, CPSBoost: 5, ClickBoost: 0},
+	{ID: "farm", Name: "Farm", Description: "Grows cookie plants", BaseCost: 1100, CPSBoost: 47, ClickBoost: 0},
+	{ID: "click_power", Name: "Better Clicks", Description: "Each click gives more cookies", BaseCost: 50, CPSBoost: 0, ClickBoost: 1},
+}
+
+func catalogByID(id string) (UpgradeDef, bool) {
+	for _, def := range Catalog {
+		if def.ID == id {
+			return def, true
+		}
+	}
+	return UpgradeDef{}, false
+}
+
+// Price は古典的なCookie Clickerの価格曲線（所有数ごとに1.15倍）に従ってコストを計算する
+func Price(def UpgradeDef, owned int) int64 {
+	return int64(math.Round(float64(def.BaseCost) * math.Pow(1.15, float64(owned))))
+}
+
+// NextPrice は state における upgradeID の「次の1個」の価格を返す。未知のIDなら0を返す
+func NextPrice(state State, upgradeID string) int64 {
+	def, ok := catalogByID(upgradeID)
+	if !ok {
+		return 0
+	}
+	return Price(def, state.Upgrades[upgradeID])
+}
@@ -0,0 +1,63 @@
+package game
+
+import "errors"
+
+// ErrUnknownUpgrade は存在しないupgrade_idが指定された場合に返す
+var ErrUnknownUpgrade = errors.New("game: unknown upgrade id")
+
+// ErrInsufficientFunds はクッキーが価格に満たない場合に返す
+var ErrInsufficientFunds = errors.New("game: insufficient cookies")
+
+// State はユーザーのゲーム状態。GameData(pythonclient)と同じ形だが、ここでは
+// Go側の購入ロジックが直接読み書きできる値として扱う
+type State struct {
+	Cookies          int64
+	CookiesPerSecond int64
+	ClickPower       int
+	Upgrades         map[string]int
+}
+
+// ApplyPurchase は state から upgradeID を1個購入した結果の状態と支払った価格を返す、
+// 副作用のない純粋関数。呼び出し側が同時実行からの保護を担う
+func ApplyPurchase(state State, upgradeID string) (State, int64, error) {
+	def, ok := catalogByID(upgradeID)
+	if !ok {
+		return state, 0, ErrUnknownUpgrade
+	}
+
+	owned := state.Upgrades[upgradeID]
+	price := Price(def, owned)
+	if state.Cookies < price {
+		return state, price, ErrInsufficientFunds
+	}
+
+	next := state
+	next.Upgrades = cloneUpgrades(state.Upgrades)
+	next.Upgrades[upgradeID] = owned + 1
+	next.Cookies = state.Cookies - price
+	next.CookiesPerSecond, next.ClickPower = recompute(next.Upgrades)
+
+	return next, price, nil
+}
+
+// recompute はアップグレードの所有数から CookiesPerSecond と ClickPower を再計算する
+func recompute(upgrades map[string]int) (int64, int) {
+	var cps int64
+	clickPower := baseClickPower
+
+	for _, def := range Catalog {
+		owned := upgrades[def.ID]
+		cps += def.CPSBoost * int64(owned)
+		clickPower += def.ClickBoost * owned
+	}
+
+	return cps, clickPower
+}
+
+func cloneUpgrades(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package game
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestApplyPurchase(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     State
+		upgradeID string
+		wantErr   error
+		wantPrice int64
+		wantOwned int
+		wantCPS   int64
+		wantClick int
+	}{
+		{
+			name:      "successful purchase deducts cost and recomputes stats",
+			state:     State{Cookies: 100, Upgrades: map[string]int{}},
+			upgradeID: "cursor",
+			wantPrice: 15,
+			wantOwned: 1,
+			wantCPS:   1,
+			wantClick: 1,
+		},
+		{
+			name:      "insufficient funds",
+			state:     State{Cookies: 10, Upgrades: map[string]int{}},
+			upgradeID: "cursor",
+			wantErr:   ErrInsufficientFunds,
+			wantPrice: 15,
+		},
+		{
+			name:      "unknown upgrade id",
+			state:     State{Cookies: 1000, Upgrades: map[string]int{}},
+			upgradeID: "does_not_exist",
+			wantErr:   ErrUnknownUpgrade,
+		},
+		{
+			name:      "price grows with owned count",
+			state:     State{Cookies: 1000, Upgrades: map[string]int{"cursor": 3}},
+			upgradeID: "cursor",
+			wantPrice: Price(UpgradeDef{BaseCost: 15}, 3),
+			wantOwned: 4,
+			wantCPS:   4,
+			wantClick: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next, price, err := ApplyPurchase(tc.state, tc.upgradeID)
+
+			if tc.wantErr != nil {
+				if err != tc.wantErr {
+					t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if price != tc.wantPrice {
+				t.Fatalf("expected price %d, got %d", tc.wantPrice, price)
+			}
+			if next.Upgrades[tc.upgradeID] != tc.wantOwned {
+				t.Fatalf("expected owned %d, got %d", tc.wantOwned, next.Upgrades[tc.upgradeID])
+			}
+			if next.Cookies != tc.state.Cookies-price {
+				t.Fatalf("expected cookies %d, got %d", tc.state.Cookies-price, next.Cookies)
+			}
+			if next.CookiesPerSecond != tc.wantCPS || next.ClickPower != tc.wantClick {
+				t.Fatalf("expected cps %d and click power %d, got %d and %d", tc.wantCPS, tc.wantClick, next.CookiesPerSecond, next.ClickPower)
+			}
+		})
+	}
+}
+
+// TestStorePurchaseConcurrentRace simulates Purchase's real usage: seed and sync
+// are backed by a single external ledger (standing in for the Python API), so
+// Store itself must hold nothing but the per-user lock. Without that lock two
+// goroutines could seed the same ledger snapshot and both sync it, so the
+// ledger's owned count would fall behind the number of successful purchases.
+func TestStorePurchaseConcurrentRace(t *testing.T) {
+	store := NewStore()
+	const userID = "racer"
+	const attempts = 50
+
+	var ledgerMu sync.Mutex
+	ledger := State{Cookies: Price(UpgradeDef{BaseCost: 15}, 0) * 10, Upgrades: map[string]int{}}
+
+	seed := func() State {
+		ledgerMu.Lock()
+		defer ledgerMu.Unlock()
+		return ledger
+	}
+	applySync := func(next State, pricePaid int64) error {
+		ledgerMu.Lock()
+		defer ledgerMu.Unlock()
+		ledger = next
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	successes := make(chan bool, attempts)
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := store.Purchase(userID, "cursor", seed, applySync)
+			successes <- err == nil
+		}()
+	}
+	wg.Wait()
+	close(successes)
+
+	owned := 0
+	for ok := range successes {
+		if ok {
+			owned++
+		}
+	}
+
+	ledgerMu.Lock()
+	final := ledger
+	ledgerMu.Unlock()
+	if final.Upgrades["cursor"] != owned {
+		t.Fatalf("owned count %d does not match number of successful purchases %d", final.Upgrades["cursor"], owned)
+	}
+	if final.Cookies < 0 {
+		t.Fatalf("cookies went negative: %d", final.Cookies)
+	}
+}
@@ -0,0 +1,55 @@
+package game
+
+import "sync"
+
+// Store serializes concurrent upgrade purchases per user so two requests can't
+// both read the same budget and double-spend it. It intentionally caches
+// nothing: cookies/CPS/click_power always come fresh from seed (the Python API
+// in production), because Python keeps accruing cookies from clicks and CPS
+// ticks independently of any purchase Go makes. Caching a snapshot here would
+// freeze the spendable balance the moment a user's first purchase happened.
+type Store struct {
+	locks sync.Map // userID -> *sync.Mutex
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	return &Store{}
+}
+
+func (s *Store) lockFor(userID string) *sync.Mutex {
+	actual, _ := s.locks.LoadOrStore(userID, &sync.Mutex{})
+	return actual.(*sync.Mutex)
+}
+
+// Purchase buys one unit of upgradeID for userID. seed fetches the current
+// authoritative state and is called fresh on every purchase, never cached, so
+// cookies earned since the last purchase are always accounted for. sync is
+// called with the resulting state and the price paid so the caller can push
+// the purchase back to the authoritative store (e.g. telling Python to debit
+// the price and update its own CPS/click_power ledger) before it's considered
+// committed - if sync fails, the purchase is not applied. The whole
+// read-modify-sync is serialized per user via lockFor.
+func (s *Store) Purchase(userID, upgradeID string, seed func() State, sync func(next State, pricePaid int64) error) (State, int64, error) {
+	lock := s.lockFor(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state := seed()
+	if state.Upgrades == nil {
+		state.Upgrades = make(map[string]int)
+	}
+
+	next, price, err := ApplyPurchase(state, upgradeID)
+	if err != nil {
+		return state, price, err
+	}
+
+	if sync != nil {
+		if err := sync(next, price); err != nil {
+			return state, price, err
+		}
+	}
+
+	return next, price, nil
+}
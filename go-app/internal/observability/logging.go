@@ -0,0 +1,73 @@
+// Package observability wires up structured logging, request correlation,
+// and Prometheus metrics as Gin middleware so individual handlers don't have
+// to know about any of it.
+package observability
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"goapp/internal/auth"
+	"goapp/internal/pythonclient"
+)
+
+// RequestIDHeader is the header used to carry the request id across services
+const RequestIDHeader = pythonclient.RequestIDHeader
+
+const requestIDContextKey = "request_id"
+
+// InitLogger configures the global zerolog logger. Call once at startup, before
+// any request comes in.
+func InitLogger() {
+	zerolog.TimeFieldFormat = time.RFC3339
+}
+
+// RequestID assigns a UUID to requests that don't already carry one, stores it
+// on the Gin context, echoes it back on the response, and attaches it to the
+// request's context so pythonclient forwards it to the Python API - that's what
+// lets logs on both sides of a call be correlated.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDContextKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(pythonclient.ContextWithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// RequestIDFromContext returns the request id assigned by RequestID, or "" if
+// the middleware hasn't run
+func RequestIDFromContext(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+// Logging emits one structured log line per request with the fields needed to
+// trace it: request id, user id (once authenticated), route, status, latency
+func Logging() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		log.Info().
+			Str("request_id", RequestIDFromContext(c)).
+			Str("user_id", c.GetString(auth.SessionUserKey)).
+			Str("method", c.Request.Method).
+			Str("route", route).
+			Int("status", c.Writer.Status()).
+			Dur("latency_ms", time.Since(start)).
+			Msg("request handled")
+	}
+}
@@ -0,0 +1,101 @@
+package observability
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cookie_clicker_http_requests_total",
+		Help: "Total HTTP requests by method, route and status",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cookie_clicker_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	pythonAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cookie_clicker_python_api_duration_seconds",
+		Help:    "Latency of calls to the Python API by operation",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	pythonAPIErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cookie_clicker_python_api_errors_total",
+		Help: "Errors returned by the Python API client by operation",
+	}, []string{"operation"})
+
+	circuitBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cookie_clicker_python_api_circuit_breaker_state",
+		Help: "Python API circuit breaker state (0=closed, 1=open, 2=half-open)",
+	})
+
+	websocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cookie_clicker_websocket_connections",
+		Help: "Current number of active WebSocket connections",
+	})
+
+	clicksPerUser = promauto.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "cookie_clicker_clicks_per_user",
+		Help:       "Accepted clicks per user, used to watch for abnormal click rates",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, []string{"user_id"})
+)
+
+// Middleware records request counts and latency histograms per route+status.
+// The route label is c.FullPath() read after c.Next() returns; requests that
+// matched no route are labelled "unmatched".
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler exposes the Prometheus registry over HTTP, for mounting at /metrics
+func Handler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// ObservePythonCall records the latency and error rate of a single Python API call
+func ObservePythonCall(operation string, duration time.Duration, err error) {
+	pythonAPIDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		pythonAPIErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+// SetCircuitBreakerState reports the Python API circuit breaker's current state
+func SetCircuitBreakerState(state int) {
+	circuitBreakerState.Set(float64(state))
+}
+
+// SetWebsocketConnections reports the number of currently open WebSocket connections
+func SetWebsocketConnections(n int) {
+	websocketConnections.Set(float64(n))
+}
+
+// RecordClick records one accepted click for a user, for the clicks/sec-per-user summary
+func RecordClick(userID string) {
+	clicksPerUser.WithLabelValues(userID).Observe(1)
+}
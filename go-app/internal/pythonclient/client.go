@@ -0,0 +1,403 @@
+// Package pythonclient はGoサーバーからPython側のゲームロジックAPIを呼び出すための
+// 唯一の経路を提供する。コネクションプーリング、タイムアウト、リトライ、サーキットブレーカーを
+// まとめて面倒を見ることで、各ハンドラが素の http.Get/http.Post を呼んで障害を握りつぶす
+// ことがないようにする。
+package pythonclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GameData はゲームの状態を表す構造体
+type GameData struct {
+	UserID           string         `json:"user_id"`
+	Cookies          int64          `json:"cookies"`
+	CookiesPerSecond int64          `json:"cookies_per_second"`
+	ClickPower       int            `json:"click_power"`
+	Upgrades         map[string]int `json:"upgrades"`
+}
+
+// UpgradeItem はアップグレードアイテムを表す構造体
+type UpgradeItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Cost        int64  `json:"cost"`
+	CPSBoost    int64  `json:"cps_boost"`
+	ClickBoost  int    `json:"click_boost"`
+	Owned       int    `json:"owned"`
+}
+
+// GameResponse は GET /game/:user_id のレスポンス
+type GameResponse struct {
+	GameData GameData      `json:"game_data"`
+	Upgrades []UpgradeItem `json:"upgrades"`
+}
+
+// ClickResponse は POST /game/:user_id/click のレスポンス
+type ClickResponse struct {
+	UserID        string `json:"user_id"`
+	CookiesEarned int64  `json:"cookies_earned"`
+	TotalCookies  int64  `json:"total_cookies"`
+	Message       string `json:"message"`
+}
+
+// SaveResponse は GET /game/:user_id/save のレスポンス
+type SaveResponse struct {
+	UserID string `json:"user_id"`
+	Saved  bool   `json:"saved"`
+}
+
+// LoadResponse は POST /game/:user_id/load のレスポンス
+type LoadResponse struct {
+	UserID string `json:"user_id"`
+	Loaded bool   `json:"loaded"`
+}
+
+// UpgradeResponse は POST /game/:user_id/upgrade のレスポンス。価格やカタログの
+// ロジックはGo側(internal/game)が持つため、ここはPython側が自分の残高・CPS・
+// クリックパワーの台帳をGoの購入結果に合わせて更新したことを示すだけの確認応答
+type UpgradeResponse struct {
+	UserID           string `json:"user_id"`
+	UpgradeID        string `json:"upgrade_id"`
+	Cookies          int64  `json:"cookies"`
+	CookiesPerSecond int64  `json:"cookies_per_second"`
+	ClickPower       int    `json:"click_power"`
+}
+
+// ErrCircuitOpen はサーキットブレーカーがオープン状態のため呼び出しを即座に棄却したことを示す
+var ErrCircuitOpen = errors.New("pythonclient: circuit breaker is open")
+
+// RequestIDHeader は呼び出し元のリクエストIDをPython API側に伝搬するヘッダー名。
+// Goサーバー側とPython側のログを相関させるために使う
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey int
+
+const requestIDContextKey contextKey = iota
+
+// ContextWithRequestID は ctx にリクエストIDを載せる。doOnce がこれを読み取り、
+// Python APIへのリクエストに RequestIDHeader として転送する
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// Metrics はPython API呼び出しのレイテンシ・エラー率・サーキットブレーカーの状態を
+// 記録するためのフック。未設定の場合は何もしない noopMetrics が使われる
+type Metrics interface {
+	ObserveCall(operation string, duration time.Duration, err error)
+	SetBreakerState(state int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveCall(string, time.Duration, error) {}
+func (noopMetrics) SetBreakerState(int)                      {}
+
+// Config はクライアントの挙動を調整するオプション
+type Config struct {
+	BaseURL             string
+	Timeout             time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	MaxRetries          int
+	RetryBaseDelay      time.Duration
+	FailureThreshold    int           // この回数連続で失敗するとサーキットを開く
+	CooldownPeriod      time.Duration // オープン後、半開状態に移るまでの待機時間
+	Metrics             Metrics       // 省略可。nilの場合は記録しない
+}
+
+// DefaultConfig は妥当なデフォルト値を持つ Config を返す
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:             baseURL,
+		Timeout:             3 * time.Second,
+		MaxIdleConnsPerHost: 20,
+		IdleConnTimeout:     90 * time.Second,
+		MaxRetries:          2,
+		RetryBaseDelay:      50 * time.Millisecond,
+		FailureThreshold:    5,
+		CooldownPeriod:      10 * time.Second,
+	}
+}
+
+// Client はPython APIへの全ての呼び出しが経由するHTTPクライアントのラッパー
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryDelay time.Duration
+	breaker    *circuitBreaker
+	metrics    Metrics
+}
+
+// New は Config を元に Client を生成する
+func New(cfg Config) *Client {
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+	}
+
+	metrics := cfg.Metrics
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+
+	return &Client{
+		baseURL: cfg.BaseURL,
+		httpClient: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+		},
+		maxRetries: cfg.MaxRetries,
+		retryDelay: cfg.RetryBaseDelay,
+		breaker:    newCircuitBreaker(cfg.FailureThreshold, cfg.CooldownPeriod),
+		metrics:    metrics,
+	}
+}
+
+// GetGame はユーザーのゲームデータとアップグレードカタログを取得する
+func (c *Client) GetGame(ctx context.Context, userID string) (*GameResponse, error) {
+	var out GameResponse
+	err := c.doTimed(ctx, "get_game", http.MethodGet, fmt.Sprintf("/api/v1/game/%s", userID), nil, &out)
+	return &out, err
+}
+
+// Click はサーバーが決定した click_power でクリックを登録する
+func (c *Client) Click(ctx context.Context, userID string, clickPower int) (*ClickResponse, error) {
+	var out ClickResponse
+	body := map[string]interface{}{"click_power": clickPower}
+	err := c.doTimed(ctx, "click", http.MethodPost, fmt.Sprintf("/api/v1/game/%s/click", userID), body, &out)
+	return &out, err
+}
+
+// Upgrade はGo側で確定した購入（価格計算・在庫チェック済み）をPythonの台帳にも
+// 適用させる。これを呼ばないと、クリック/CPSで増え続けるPython側の残高とGoの
+// 購入済みアップグレード台帳が食い違い、購入のたびに残高が凍結されたまま不整合になる
+func (c *Client) Upgrade(ctx context.Context, userID, upgradeID string, pricePaid int64) (*UpgradeResponse, error) {
+	var out UpgradeResponse
+	body := map[string]interface{}{"upgrade_id": upgradeID, "price_paid": pricePaid}
+	err := c.doTimed(ctx, "upgrade", http.MethodPost, fmt.Sprintf("/api/v1/game/%s/upgrade", userID), body, &out)
+	return &out, err
+}
+
+// Save はユーザーの現在の状態を永続化する
+func (c *Client) Save(ctx context.Context, userID string) (*SaveResponse, error) {
+	var out SaveResponse
+	err := c.doTimed(ctx, "save", http.MethodGet, fmt.Sprintf("/api/v1/game/%s/save", userID), nil, &out)
+	return &out, err
+}
+
+// Load は永続化済みの状態を読み込む
+func (c *Client) Load(ctx context.Context, userID string) (*LoadResponse, error) {
+	var out LoadResponse
+	err := c.doTimed(ctx, "load", http.MethodPost, fmt.Sprintf("/api/v1/game/%s/load", userID), nil, &out)
+	return &out, err
+}
+
+// doTimed は do を呼び出しつつ、呼び出しのレイテンシ・エラー・サーキットブレーカーの
+// 状態を Metrics に記録する
+func (c *Client) doTimed(ctx context.Context, operation, method, path string, reqBody interface{}, out interface{}) error {
+	start := time.Now()
+	err := c.do(ctx, method, path, reqBody, out)
+	c.metrics.ObserveCall(operation, time.Since(start), err)
+	c.metrics.SetBreakerState(int(c.breaker.State()))
+	return err
+}
+
+// do はサーキットブレーカーのチェック、リトライ、JSONのエンコード/デコードをまとめて行う
+func (c *Client) do(ctx context.Context, method, path string, reqBody interface{}, out interface{}) error {
+	if !c.breaker.Allow() {
+		return ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.retryDelay * time.Duration(1<<uint(attempt-1))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err := c.doOnce(ctx, method, path, reqBody, out)
+		if err == nil {
+			c.breaker.RecordSuccess()
+			return nil
+		}
+
+		lastErr = err
+		if !isRetryable(err) {
+			// 4xxなどクライアント側の誤りはPython API自体の健全性を示さないため、
+			// 失敗としては計上しない。ただし半開状態のプローブはこれで解消しておかないと、
+			// 次にAllowを呼ぶ誰も通らないまま永久に半開状態で詰まってしまう
+			c.breaker.RecordNonFailure()
+			return err
+		}
+	}
+
+	c.breaker.RecordFailure()
+	return lastErr
+}
+
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("pythonclient: upstream returned status %d", e.StatusCode)
+}
+
+func isRetryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	// ネットワークエラー（タイムアウト、接続拒否など）はリトライ対象
+	return true
+}
+
+func (c *Client) doOnce(ctx context.Context, method, path string, reqBody interface{}, out interface{}) error {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("pythonclient: failed to encode request: %w", err)
+		}
+		bodyReader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("pythonclient: failed to build request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := requestIDFromContext(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pythonclient: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("pythonclient: failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("pythonclient: failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// circuitBreaker は closed -> open -> half-open -> closed の状態遷移を行う
+// 連続失敗カウンタベースのシンプルなサーキットブレーカー
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            breakerState
+	failureThreshold int
+	cooldown         time.Duration
+	consecutiveFails int
+	openedAt         time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{failureThreshold: failureThreshold, cooldown: cooldown}
+}
+
+// State は現在のブレーカー状態を返す
+func (b *circuitBreaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow は現在の状態で呼び出しを許可するか判定する。オープン状態でクールダウンを
+// 過ぎていれば半開状態に移行して1回だけ試行を許可する。半開状態で既にプローブが
+// 進行中（RecordSuccess/RecordFailureで解消されるまで）の呼び出しは全て棄却する
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) >= b.cooldown {
+			b.state = stateHalfOpen
+			return true
+		}
+		return false
+	case stateHalfOpen:
+		// プローブは既に1件進行中。結果が出るまで他の呼び出しは通さない
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess は呼び出し成功を記録し、ブレーカーを閉じる
+func (b *circuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFails = 0
+	b.state = stateClosed
+}
+
+// RecordNonFailure は、呼び出し自体はPython APIの健全性を示さない結果（4xxなど）に
+// 終わったことを記録する。成功としては扱わないが、半開状態で進行中だったプローブは
+// これで解消しないと誰も二度とAllowを通過できなくなるため、半開状態のときだけ閉じる
+func (b *circuitBreaker) RecordNonFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen {
+		b.state = stateClosed
+		b.consecutiveFails = 0
+	}
+}
+
+// RecordFailure は呼び出し失敗を記録し、連続失敗数が閾値に達していれば（または半開状態なら）ブレーカーを開く
+func (b *circuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFails++
+	if b.state == stateHalfOpen || b.consecutiveFails >= b.failureThreshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,111 @@
+// Package ratelimit はユーザーごとのクリックレート制限を提供する。
+// 単一インスタンスではプロセス内メモリのトークンバケットで十分だが、
+// 複数インスタンスにスケールアウトする場合は Redis 実装に差し替えられるよう
+// Limiter インターフェースを介して利用する。
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter はキー（user_idやセッションIDなど）ごとにリクエストを許可するか判定する
+type Limiter interface {
+	// Allow はキーについて1リクエスト分のトークンを消費できるか判定する。
+	// 許可できない場合、次に許可されるまでの目安の待ち時間を返す
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration)
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// MemoryLimiter はプロセス内メモリで管理するトークンバケット実装。
+// 単一インスタンス構成（開発環境や小規模デプロイ）向け
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	rate    float64 // 秒あたりに補充されるトークン数
+	burst   float64 // バケットの最大容量
+}
+
+// NewMemoryLimiter は毎秒 rate トークンを補充し、最大 burst トークンまで貯められる
+// トークンバケットリミッタを生成する
+func NewMemoryLimiter(rate float64, burst int) *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*tokenBucket),
+		rate:    rate,
+		burst:   float64(burst),
+	}
+}
+
+// Allow はキーのバケットからトークンを1つ消費できるか判定する
+func (l *MemoryLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastRefill: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens += elapsed * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		deficit := 1 - b.tokens
+		retryAfter := time.Duration(deficit/l.rate*1000) * time.Millisecond
+		return false, retryAfter
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// RedisLimiter はマルチインスタンス構成向けに Redis の INCR + TTL で
+// 固定ウィンドウのレート制限を行う実装。トークンバケットほど滑らかではないが、
+// インスタンス間で状態を共有できる
+type RedisLimiter struct {
+	client *redis.Client
+	limit  int
+	window time.Duration
+}
+
+// NewRedisLimiter は window 期間につき limit リクエストまで許可する RedisLimiter を生成する
+func NewRedisLimiter(client *redis.Client, limit int, window time.Duration) *RedisLimiter {
+	return &RedisLimiter{client: client, limit: limit, window: window}
+}
+
+// Allow はキーに紐づくウィンドウ内のカウンタをインクリメントし、上限を超えていないか判定する
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	redisKey := "ratelimit:" + key
+
+	count, err := l.client.Incr(ctx, redisKey).Result()
+	if err != nil {
+		// Redis障害時はフェイルオープンにして可用性を優先する
+		return true, 0
+	}
+	if count == 1 {
+		l.client.Expire(ctx, redisKey, l.window)
+	}
+
+	if count > int64(l.limit) {
+		ttl, err := l.client.TTL(ctx, redisKey).Result()
+		if err != nil || ttl < 0 {
+			ttl = l.window
+		}
+		return false, ttl
+	}
+
+	return true, 0
+}
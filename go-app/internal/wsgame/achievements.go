@@ -0,0 +1,62 @@
+package wsgame
+
+import "sync"
+
+// achievement is a milestone reported to the client as an achievement_unlocked event
+type achievement struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// firstUpgradeAchievement unlocks the first time a player owns any upgrade at all
+var firstUpgradeAchievement = achievement{ID: "first_upgrade", Name: "Getting Some Help"}
+
+// cookieMilestones are evaluated against a player's current cookie count (the
+// balance, which purchases reduce), not a lifetime total.
+// Order doesn't matter; achievementTracker checks every entry each time
+var cookieMilestones = []struct {
+	Cookies int64
+	achievement
+}{
+	{Cookies: 100, achievement: achievement{ID: "hundred_cookies", Name: "Getting the Hang of It"}},
+	{Cookies: 10_000, achievement: achievement{ID: "ten_thousand_cookies", Name: "Cookie Factory"}},
+	{Cookies: 1_000_000, achievement: achievement{ID: "million_cookies", Name: "Cookie Tycoon"}},
+}
+
+// achievementTracker remembers which achievements each user already unlocked so the
+// same achievement_unlocked event isn't sent to a user more than once for the lifetime
+// of the Hub; entries are not cleared when a connection closes
+type achievementTracker struct {
+	mu       sync.Mutex
+	unlocked map[string]map[string]bool
+}
+
+func newAchievementTracker() *achievementTracker {
+	return &achievementTracker{unlocked: make(map[string]map[string]bool)}
+}
+
+// Check returns the achievements userID newly qualifies for given their current total
+// cookies and total upgrades owned, marking them unlocked so they aren't reported again
+func (t *achievementTracker) Check(userID string, cookies int64, upgradesOwned int) []achievement {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := t.unlocked[userID]
+	if seen == nil {
+		seen = make(map[string]bool)
+		t.unlocked[userID] = seen
+	}
+
+	var newly []achievement
+	if upgradesOwned > 0 && !seen[firstUpgradeAchievement.ID] {
+		seen[firstUpgradeAchievement.ID] = true
+		newly = append(newly, firstUpgradeAchievement)
+	}
+	for _, m := range cookieMilestones {
+		if cookies >= m.Cookies && !seen[m.ID] {
+			seen[m.ID] = true
+			newly = append(newly, m.achievement)
+		}
+	}
+	return newly
+}
@@ -0,0 +1,120 @@
+package wsgame
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	sendBufferSize = 16
+)
+
+// clientMessage はクライアントからソケット経由で送られてくるメッセージの共通フォーマット
+type clientMessage struct {
+	Type       string `json:"type"`
+	UpgradeID  string `json:"upgrade_id,omitempty"`
+	ClickPower int    `json:"click_power,omitempty"`
+}
+
+// event はサーバーからクライアントへ送るイベントの共通フォーマット
+type event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Client は1つのWebSocket接続を表す。読み取りはreadPump、書き込みはwritePumpが
+// それぞれ専用のgoroutineで担当し、writePumpへはsendチャネル経由でのみアクセスする
+type Client struct {
+	hub    *Hub
+	conn   *websocket.Conn
+	userID string
+	send   chan []byte
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+	return &Client{hub: hub, conn: conn, userID: userID, send: make(chan []byte, sendBufferSize)}
+}
+
+// readPump はクライアントからのメッセージ（クリックやアップグレード購入）を処理する。
+// 接続が切れるかエラーになるまでブロックするので、接続ごとに専用goroutineで呼び出すこと
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, raw, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("websocket read error for user %s: %v", c.userID, err)
+			}
+			return
+		}
+
+		var msg clientMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			log.Printf("failed to parse websocket message from user %s: %v", c.userID, err)
+			continue
+		}
+
+		c.hub.handleClientMessage(c, msg)
+	}
+}
+
+// writePump はhubやティッカーから送られてくるイベントをこの接続に書き込む唯一の場所。
+// gorilla/websocketのコネクションは並行書き込みを許さないためgoroutineを1つに絞る
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) sendEvent(evt event) {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		log.Printf("failed to encode websocket event for user %s: %v", c.userID, err)
+		return
+	}
+
+	select {
+	case c.send <- data:
+	default:
+		// バッファが詰まっている場合は接続を切る。クライアントが受信を止めているとみなす
+		log.Printf("send buffer full for user %s, dropping connection", c.userID)
+		c.hub.unregister <- c
+	}
+}
@@ -0,0 +1,264 @@
+// Package wsgame はクッキーの増加をポーリングなしでクライアントに届けるための
+// WebSocketチャネルを実装する。クリックやアップグレード購入も同じソケット上で
+// 受け付けることで、高頻度の操作でHTTPのオーバーヘッドを避ける。
+package wsgame
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"goapp/internal/auth"
+	"goapp/internal/game"
+	"goapp/internal/pythonclient"
+	"goapp/internal/ratelimit"
+)
+
+// ClickPowerCache はクリックパワーの正規値を参照・更新するための依存先。
+// main側の sync.Map をラップしたものを渡す想定
+type ClickPowerCache interface {
+	Get(userID string) int
+	Set(userID string, power int)
+}
+
+// Config は Hub の生成に必要な依存をまとめたもの
+type Config struct {
+	Python       *pythonclient.Client
+	ClickLimiter ratelimit.Limiter
+	ClickPower   ClickPowerCache
+	GameStore    *game.Store
+	SeedState    func(ctx context.Context, userID string) game.State
+	TickInterval time.Duration
+	AllowOrigins []string
+
+	// OnConnectionsChanged は接続数が変化するたびに現在の接続数で呼ばれる。
+	// 省略可（nilの場合は呼ばれない）。metricsへの反映などに使う
+	OnConnectionsChanged func(count int)
+}
+
+// Hub は接続中の全クライアントを管理し、定期的なtickイベントの配信と
+// クライアント発のメッセージのディスパッチを行う
+type Hub struct {
+	cfg          Config
+	upgrader     websocket.Upgrader
+	clients      map[*Client]bool
+	register     chan *Client
+	unregister   chan *Client
+	shutdown     chan struct{}
+	mu           sync.Mutex
+	achievements *achievementTracker
+}
+
+// NewHub は Config を元に Hub を生成し、ティッカーとメッセージループのgoroutineを開始する
+func NewHub(cfg Config) *Hub {
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = time.Second
+	}
+
+	allowed := make(map[string]bool, len(cfg.AllowOrigins))
+	for _, origin := range cfg.AllowOrigins {
+		allowed[origin] = true
+	}
+
+	h := &Hub{
+		cfg:          cfg,
+		clients:      make(map[*Client]bool),
+		register:     make(chan *Client),
+		unregister:   make(chan *Client),
+		shutdown:     make(chan struct{}),
+		achievements: newAchievementTracker(),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || allowed[origin] || allowed["*"]
+			},
+		},
+	}
+
+	go h.run()
+	return h
+}
+
+// HandleWS はセッションを検証済みの接続をアップグレードし、読み書きのgoroutineを開始する。
+// auth.RequireAuth を経由したルートにぶら下げて使う
+func (h *Hub) HandleWS(c *gin.Context) {
+	userID := c.GetString(auth.SessionUserKey)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("websocket upgrade failed for user %s: %v", userID, err)
+		return
+	}
+
+	client := newClient(h, conn, userID)
+	h.register <- client
+
+	go client.writePump()
+	go client.readPump()
+}
+
+func (h *Hub) run() {
+	ticker := time.NewTicker(h.cfg.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case client := <-h.register:
+			h.mu.Lock()
+			h.clients[client] = true
+			count := len(h.clients)
+			h.mu.Unlock()
+			h.reportConnectionCount(count)
+
+		case client := <-h.unregister:
+			h.mu.Lock()
+			if _, ok := h.clients[client]; ok {
+				delete(h.clients, client)
+				close(client.send)
+			}
+			count := len(h.clients)
+			h.mu.Unlock()
+			h.reportConnectionCount(count)
+
+		case <-ticker.C:
+			// broadcastTicks is dispatched off this goroutine because it calls
+			// Client.sendEvent, which falls back to a blocking send on h.unregister
+			// when a client's buffer is full. run() is the only reader of
+			// h.unregister, so running broadcastTicks inline would let a single
+			// full buffer wedge this loop forever. See chunk0-4 review
+			go h.broadcastTicks()
+
+		case <-h.shutdown:
+			h.closeAll()
+			h.reportConnectionCount(0)
+			return
+		}
+	}
+}
+
+// broadcastTicks はPython APIから現在のクッキー数・CPSを取得し、各接続に配信する
+func (h *Hub) broadcastTicks() {
+	h.mu.Lock()
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.TickInterval)
+	defer cancel()
+
+	for _, client := range clients {
+		gameResp, err := h.cfg.Python.GetGame(ctx, client.userID)
+		if err != nil {
+			log.Printf("websocket tick: failed to fetch game data for user %s: %v", client.userID, err)
+			continue
+		}
+		if gameResp.GameData.ClickPower > 0 {
+			h.cfg.ClickPower.Set(client.userID, gameResp.GameData.ClickPower)
+		}
+		client.sendEvent(event{
+			Type: "tick",
+			Data: gin.H{
+				"cookies": gameResp.GameData.Cookies,
+				"cps":     gameResp.GameData.CookiesPerSecond,
+			},
+		})
+
+		h.sendAchievements(client, gameResp.GameData.Cookies, totalOwnedFromState(gameResp.GameData.Upgrades))
+	}
+}
+
+// sendAchievements emits one achievement_unlocked event per newly-unlocked achievement
+func (h *Hub) sendAchievements(client *Client, cookies int64, upgradesOwned int) {
+	for _, a := range h.achievements.Check(client.userID, cookies, upgradesOwned) {
+		client.sendEvent(event{Type: "achievement_unlocked", Data: a})
+	}
+}
+
+// totalOwnedFromState sums the owned count across a game.State's upgrade map
+func totalOwnedFromState(upgrades map[string]int) int {
+	total := 0
+	for _, owned := range upgrades {
+		total += owned
+	}
+	return total
+}
+
+// handleClientMessage はソケット経由で届いたクリック/アップグレードのリクエストを処理する
+func (h *Hub) handleClientMessage(c *Client, msg clientMessage) {
+	ctx := context.Background()
+
+	switch msg.Type {
+	case "click":
+		allowed, retryAfter := h.cfg.ClickLimiter.Allow(ctx, c.userID)
+		if !allowed {
+			c.sendEvent(event{Type: "rate_limited", Data: gin.H{"retry_after_ms": retryAfter.Milliseconds()}})
+			return
+		}
+
+		clickPower := h.cfg.ClickPower.Get(c.userID)
+		result, err := h.cfg.Python.Click(ctx, c.userID, clickPower)
+		if err != nil {
+			log.Printf("websocket click failed for user %s: %v", c.userID, err)
+			return
+		}
+		c.sendEvent(event{Type: "tick", Data: gin.H{"cookies": result.TotalCookies}})
+
+	case "upgrade":
+		next, price, err := h.cfg.GameStore.Purchase(c.userID, msg.UpgradeID, func() game.State {
+			return h.cfg.SeedState(ctx, c.userID)
+		}, func(next game.State, pricePaid int64) error {
+			_, err := h.cfg.Python.Upgrade(ctx, c.userID, msg.UpgradeID, pricePaid)
+			return err
+		})
+		if err != nil {
+			c.sendEvent(event{Type: "upgrade_rejected", Data: gin.H{"upgrade_id": msg.UpgradeID, "error": err.Error()}})
+			return
+		}
+
+		h.cfg.ClickPower.Set(c.userID, next.ClickPower)
+		c.sendEvent(event{
+			Type: "upgrade_purchased",
+			Data: gin.H{
+				"upgrade_id": msg.UpgradeID,
+				"price_paid": price,
+				"next_price": game.NextPrice(next, msg.UpgradeID),
+				"game_data":  next,
+			},
+		})
+		h.sendAchievements(c, next.Cookies, totalOwnedFromState(next.Upgrades))
+
+	default:
+		log.Printf("unknown websocket message type %q from user %s", msg.Type, c.userID)
+	}
+}
+
+func (h *Hub) reportConnectionCount(count int) {
+	if h.cfg.OnConnectionsChanged != nil {
+		h.cfg.OnConnectionsChanged(count)
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		close(client.send)
+		client.conn.Close()
+		delete(h.clients, client)
+	}
+}
+
+// Shutdown は全てのWebSocket接続を閉じてHubのループを停止する。サーバーのシャットダウン時に呼ぶ
+func (h *Hub) Shutdown() {
+	close(h.shutdown)
+}
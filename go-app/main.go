@@ -1,40 +1,124 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	sessionsredis "github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"goapp/internal/auth"
+	"goapp/internal/game"
+	"goapp/internal/observability"
+	"goapp/internal/pythonclient"
+	"goapp/internal/ratelimit"
+	"goapp/internal/wsgame"
 )
 
-// GameData はゲームの状態を表す構造体
-type GameData struct {
-	UserID           string         `json:"user_id"`
-	Cookies          int64          `json:"cookies"`
-	CookiesPerSecond int64          `json:"cookies_per_second"`
-	ClickPower       int            `json:"click_power"`
-	Upgrades         map[string]int `json:"upgrades"`
+// PythonAPIの設定
+var pythonAPIURL = getEnv("PYTHON_API_URL", "http://localhost:8001")
+
+// pythonAPI はPython側のゲームロジックAPIを呼び出すための唯一の経路。
+// タイムアウト・リトライ・サーキットブレーカーは pythonclient.Client に集約されている
+var pythonAPI = newPythonAPI()
+
+func newPythonAPI() *pythonclient.Client {
+	cfg := pythonclient.DefaultConfig(pythonAPIURL)
+	cfg.Metrics = pythonMetricsAdapter{}
+	return pythonclient.New(cfg)
 }
 
-// UpgradeItem はアップグレードアイテムを表す構造体
-type UpgradeItem struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Cost        int64  `json:"cost"`
-	CPSBoost    int64  `json:"cps_boost"`
-	ClickBoost  int    `json:"click_boost"`
-	Owned       int    `json:"owned"`
+// pythonMetricsAdapter はPython APIクライアントのメトリクスフックを
+// observability パッケージのPrometheus計測に橋渡しする
+type pythonMetricsAdapter struct{}
+
+func (pythonMetricsAdapter) ObserveCall(operation string, duration time.Duration, err error) {
+	observability.ObservePythonCall(operation, duration, err)
 }
 
-// PythonAPIの設定
-var pythonAPIURL = getEnv("PYTHON_API_URL", "http://localhost:8001")
+func (pythonMetricsAdapter) SetBreakerState(state int) {
+	observability.SetCircuitBreakerState(state)
+}
+
+// アカウントストア。今はプロセス内メモリだが auth.Store を実装していれば差し替え可能
+var accountStore = auth.NewMemoryStore()
+
+// クリックレートリミッタ。CLICK_RATE_LIMIT_BACKEND=redis で複数インスタンス対応に切り替わる
+var clickLimiter = newClickLimiter()
+
+// gameStore はユーザーごとのアップグレード購入を直列化する（状態は保持せず、ユーザーごとのロックのみ持つ）
+var gameStore = game.NewStore()
+
+// clickPowerCache はユーザーごとの正規 ClickPower を保持する。
+// クライアントから送られてくる click_power は信用せず、ここにキャッシュした値を使う
+var clickPowerCache sync.Map // userID(string) -> clickPower(int)
+
+func newClickLimiter() ratelimit.Limiter {
+	rate, _ := strconv.ParseFloat(getEnv("CLICK_RATE_LIMIT", "10"), 64)
+	burst, _ := strconv.Atoi(getEnv("CLICK_BURST", "20"))
+	if rate <= 0 {
+		rate = 10
+	}
+	if burst <= 0 {
+		burst = 20
+	}
+
+	if getEnv("CLICK_RATE_LIMIT_BACKEND", "memory") == "redis" {
+		client := redis.NewClient(&redis.Options{Addr: getEnv("REDIS_URL", "localhost:6379")})
+		return ratelimit.NewRedisLimiter(client, burst, time.Second)
+	}
+
+	return ratelimit.NewMemoryLimiter(rate, burst)
+}
+
+func cachedClickPower(userID string) int {
+	if v, ok := clickPowerCache.Load(userID); ok {
+		return v.(int)
+	}
+	return 1
+}
+
+// clickPowerCacheAdapter は main.go のパッケージレベル sync.Map を
+// wsgame.ClickPowerCache インターフェースとして公開する
+type clickPowerCacheAdapter struct{}
+
+func (clickPowerCacheAdapter) Get(userID string) int { return cachedClickPower(userID) }
+
+func (clickPowerCacheAdapter) Set(userID string, power int) { clickPowerCache.Store(userID, power) }
+
+func newGameHub() *wsgame.Hub {
+	tickMs, _ := strconv.Atoi(getEnv("WS_TICK_INTERVAL_MS", "1000"))
+	if tickMs <= 0 {
+		tickMs = 1000
+	}
+
+	return wsgame.NewHub(wsgame.Config{
+		Python:               pythonAPI,
+		ClickLimiter:         clickLimiter,
+		ClickPower:           clickPowerCacheAdapter{},
+		GameStore:            gameStore,
+		SeedState:            seedGameState,
+		TickInterval:         time.Duration(tickMs) * time.Millisecond,
+		AllowOrigins:         strings.Split(getEnv("FRONTEND_ORIGIN", "http://localhost:3000"), ","),
+		OnConnectionsChanged: observability.SetWebsocketConnections,
+	})
+}
+
+var gameHub = newGameHub()
 
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -44,20 +128,31 @@ func getEnv(key, fallback string) string {
 }
 
 func main() {
+	observability.InitLogger()
+
 	// Ginモードの設定
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.DebugMode)
 	}
 
-	r := gin.Default()
+	// gin.Default() のリカバリに加えて、リクエストID付与と構造化ログ・
+	// Prometheusメトリクス記録のミドルウェアを差し込む（gin.Logger は使わず構造化ログで置き換える）
+	r := gin.New()
+	r.Use(gin.Recovery(), observability.RequestID(), observability.Logging(), observability.Middleware())
 
-	// CORS設定
+	// CORS設定: 認証Cookieを送るため AllowOrigins はワイルドカード不可。
+	// フロントエンドのオリジンは環境変数で指定する（カンマ区切りで複数可）
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"*"}
+	config.AllowOrigins = strings.Split(getEnv("FRONTEND_ORIGIN", "http://localhost:3000"), ",")
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"*"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
+	config.AllowCredentials = true
 	r.Use(cors.New(config))
 
+	// セッションミドルウェア: デフォルトは署名付きCookieストア、
+	// SESSION_STORE=redis を設定すると REDIS_URL を使った Redis ストアに切り替わる
+	r.Use(sessions.Sessions("cookie_clicker_session", newSessionStore()))
+
 	// 静的ファイルの配信
 	r.Static("/static", "./static")
 	r.LoadHTMLGlob("templates/*")
@@ -70,29 +165,96 @@ func main() {
 	log.Printf("Starting server on port %s", port)
 	log.Printf("Python API URL: %s", pythonAPIURL)
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// シグナルを受けたら全WebSocket接続を閉じてからHTTPサーバーをシャットダウンする
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+	gameHub.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatal("Server forced to shutdown:", err)
 	}
+
+	log.Println("Server exited")
+}
+
+// newSessionStore は SESSION_STORE 環境変数に応じてセッションストアを選択する
+func newSessionStore() sessions.Store {
+	secret := []byte(getEnv("SESSION_SECRET", "dev-insecure-secret-change-me"))
+
+	var store sessions.Store
+	if getEnv("SESSION_STORE", "cookie") == "redis" {
+		redisStore, err := sessionsredis.NewStore(10, "tcp", getEnv("REDIS_URL", "localhost:6379"), "", secret)
+		if err != nil {
+			log.Fatalf("Failed to create redis session store: %v", err)
+		}
+		store = redisStore
+	} else {
+		store = cookie.NewStore(secret)
+	}
+
+	// フロントエンドは別オリジンからCookie付きで呼んでくる（CORS AllowCredentials有効）ため、
+	// SameSite=None; Secure でないとブラウザがセッションCookieを送ってくれない。
+	// ローカルの平文HTTP開発環境向けに SESSION_COOKIE_SECURE=false で無効化できるようにする
+	store.Options(sessions.Options{
+		Path:     "/",
+		MaxAge:   30 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   getEnv("SESSION_COOKIE_SECURE", "true") != "false",
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	return store
 }
 
 func setupRoutes(r *gin.Engine) {
 	// メインページ
 	r.GET("/", handleHome)
 
+	authHandler := auth.NewHandler(accountStore)
+
 	// API エンドポイント
 	api := r.Group("/api/v1")
 	{
-		api.GET("/game/:user_id", handleGetGameData)
-		api.POST("/game/:user_id/click", handleClick)
-		api.POST("/game/:user_id/upgrade", handleUpgrade)
-		api.GET("/game/:user_id/save", handleSaveGame)
-		api.POST("/game/:user_id/load", handleLoadGame)
+		api.POST("/auth/register", authHandler.Register)
+		api.POST("/auth/login", authHandler.Login)
+		api.POST("/auth/logout", authHandler.Logout)
+
+		game := api.Group("/game")
+		game.Use(auth.RequireAuth())
+		{
+			game.GET("", handleGetGameData)
+			game.POST("/click", handleClick)
+			game.POST("/upgrade", handleUpgrade)
+			game.GET("/save", handleSaveGame)
+			game.POST("/load", handleLoadGame)
+		}
 	}
 
+	// リアルタイム更新用WebSocket。ポーリングせずにtick/購入イベントを配信する
+	ws := r.Group("/ws")
+	ws.Use(auth.RequireAuth())
+	ws.GET("/game", gameHub.HandleWS)
+
 	// ヘルスチェック
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+
+	// Prometheusスクレイプ用
+	r.GET("/metrics", observability.Handler())
 }
 
 func handleHome(c *gin.Context) {
@@ -102,43 +264,34 @@ func handleHome(c *gin.Context) {
 }
 
 func handleGetGameData(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.GetString(auth.SessionUserKey)
 
 	log.Printf("Getting game data for user: %s", userID)
 
 	// Python APIからデータを取得を試行
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/game/%s", pythonAPIURL, userID))
+	gameResp, err := pythonAPI.GetGame(c.Request.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to connect to Python API: %v", err)
+		log.Printf("Failed to fetch game data from Python API: %v", err)
 		// フォールバック: デフォルトデータを返す
 		sendDefaultGameData(c, userID)
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Python API returned status: %d", resp.StatusCode)
-		sendDefaultGameData(c, userID)
-		return
-	}
-
-	// レスポンスをそのままクライアントに転送
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read Python API response: %v", err)
-		sendDefaultGameData(c, userID)
-		return
-	}
 
 	log.Printf("Successfully retrieved game data for user: %s", userID)
-	c.Header("Content-Type", "application/json")
-	c.Data(http.StatusOK, "application/json", body)
+	if gameResp.GameData.ClickPower > 0 {
+		clickPowerCache.Store(userID, gameResp.GameData.ClickPower)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"game_data": gameResp.GameData,
+		"upgrades":  gameResp.Upgrades,
+	})
 }
 
 func sendDefaultGameData(c *gin.Context, userID string) {
 	log.Printf("Sending default game data for user: %s", userID)
+	clickPowerCache.Store(userID, 1)
 
-	gameData := GameData{
+	gameData := pythonclient.GameData{
 		UserID:           userID,
 		Cookies:          0,
 		CookiesPerSecond: 0,
@@ -146,7 +299,7 @@ func sendDefaultGameData(c *gin.Context, userID string) {
 		Upgrades:         make(map[string]int),
 	}
 
-	upgrades := []UpgradeItem{
+	upgrades := []pythonclient.UpgradeItem{
 		{
 			ID:          "cursor",
 			Name:        "Cursor",
@@ -192,78 +345,50 @@ func sendDefaultGameData(c *gin.Context, userID string) {
 }
 
 func handleClick(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.GetString(auth.SessionUserKey)
 
-	log.Printf("=== CLICK DEBUG START ===")
 	log.Printf("Processing click for user: %s", userID)
-	log.Printf("Python API URL: %s", pythonAPIURL)
 
-	// リクエストボディを構造体にバインド
-	var clickReq struct {
-		ClickPower int `json:"click_power"`
-	}
-
-	if err := c.ShouldBindJSON(&clickReq); err != nil {
-		log.Printf("Failed to bind click request: %v", err)
-		clickReq.ClickPower = 1 // デフォルト値
+	allowed, retryAfter := clickLimiter.Allow(c.Request.Context(), userID)
+	if !allowed {
+		log.Printf("Rate limit exceeded for user %s, retry after %s", userID, retryAfter)
+		c.Header("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "click rate limit exceeded"})
+		return
 	}
 
-	log.Printf("Click request parsed: %+v", clickReq)
-
-	// Python APIにクリックリクエストを送信
-	reqBody := map[string]interface{}{
-		"click_power": clickReq.ClickPower,
+	// クライアントが送ってくる click_power は信用しない。サーバー側でキャッシュしている
+	// 正規の値（Pythonから取得したもの）だけを使うことでクライアント側の改ざんを防ぐ
+	var clientClickReq struct {
+		ClickPower int `json:"click_power"`
 	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		log.Printf("Failed to marshal click request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode request"})
-		return
+	if err := c.ShouldBindJSON(&clientClickReq); err == nil && clientClickReq.ClickPower != cachedClickPower(userID) {
+		log.Printf("Suspected cheating: user %s submitted click_power=%d but authoritative value is %d", userID, clientClickReq.ClickPower, cachedClickPower(userID))
 	}
 
-	apiURL := fmt.Sprintf("%s/api/v1/game/%s/click", pythonAPIURL, userID)
-	log.Printf("Sending POST request to: %s", apiURL)
-	log.Printf("Request body: %s", string(jsonData))
-
-	resp, err := http.Post(
-		apiURL,
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	clickPower := cachedClickPower(userID)
+	log.Printf("Using authoritative click_power=%d for user %s", clickPower, userID)
 
+	result, err := pythonAPI.Click(c.Request.Context(), userID, clickPower)
 	if err != nil {
-		log.Printf("*** FALLBACK TRIGGERED *** Error connecting to Python API: %v", err)
+		log.Printf("Failed to register click with Python API: %v", err)
 		// フォールバック処理
+		observability.RecordClick(userID)
 		c.JSON(http.StatusOK, gin.H{
 			"user_id":        userID,
-			"cookies_earned": clickReq.ClickPower,
-			"total_cookies":  clickReq.ClickPower,
+			"cookies_earned": clickPower,
+			"total_cookies":  clickPower,
 			"message":        "Cookie clicked! (Local fallback - Python API unavailable)",
 		})
 		return
 	}
-	defer resp.Body.Close()
 
-	log.Printf("Python API response status: %d", resp.StatusCode)
-
-	// Python APIのレスポンスをクライアントに転送
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read Python API response: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read Python API response"})
-		return
-	}
-
-	log.Printf("Python API response body: %s", string(body))
-	log.Printf("=== CLICK DEBUG END ===")
-
-	c.Header("Content-Type", "application/json")
-	c.Data(resp.StatusCode, "application/json", body)
+	observability.RecordClick(userID)
+	c.JSON(http.StatusOK, result)
 }
 
 func handleUpgrade(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.GetString(auth.SessionUserKey)
 
 	var request struct {
 		UpgradeID string `json:"upgrade_id"`
@@ -274,39 +399,93 @@ func handleUpgrade(c *gin.Context) {
 		return
 	}
 
-	// アップグレード処理をPython APIに委譲
-	response := gin.H{
+	ctx := c.Request.Context()
+	next, price, err := gameStore.Purchase(userID, request.UpgradeID, func() game.State {
+		return seedGameState(ctx, userID)
+	}, func(next game.State, pricePaid int64) error {
+		if _, err := pythonAPI.Upgrade(ctx, userID, request.UpgradeID, pricePaid); err != nil {
+			log.Printf("Failed to sync upgrade purchase to Python API for user %s: %v", userID, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, game.ErrUnknownUpgrade):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, game.ErrInsufficientFunds):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "price": price})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record upgrade purchase"})
+		}
+		return
+	}
+
+	clickPowerCache.Store(userID, next.ClickPower)
+
+	c.JSON(http.StatusOK, gin.H{
 		"user_id":    userID,
 		"upgrade_id": request.UpgradeID,
 		"success":    true,
-		"message":    "Upgrade purchased!",
+		"price_paid": price,
+		"next_price": game.NextPrice(next, request.UpgradeID),
+		"game_data": gin.H{
+			"cookies":            next.Cookies,
+			"cookies_per_second": next.CookiesPerSecond,
+			"click_power":        next.ClickPower,
+			"upgrades":           next.Upgrades,
+		},
+	})
+}
+
+// seedGameState は購入のたびにPython APIから現在の正規状態を取得する
+// （失敗時はデフォルト値から）。game.Store はこれをキャッシュしないため、
+// クリックやCPSティックで増えた残高も購入時に必ず反映される
+func seedGameState(ctx context.Context, userID string) game.State {
+	resp, err := pythonAPI.GetGame(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to seed game state for user %s, using defaults: %v", userID, err)
+		return game.State{ClickPower: 1, Upgrades: make(map[string]int)}
 	}
 
-	c.JSON(http.StatusOK, response)
+	return game.State{
+		Cookies:          resp.GameData.Cookies,
+		CookiesPerSecond: resp.GameData.CookiesPerSecond,
+		ClickPower:       resp.GameData.ClickPower,
+		Upgrades:         resp.GameData.Upgrades,
+	}
 }
 
 func handleSaveGame(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.GetString(auth.SessionUserKey)
 
-	// ゲーム保存処理をPython APIに委譲
-	response := gin.H{
-		"user_id": userID,
-		"saved":   true,
-		"message": "Game saved successfully!",
+	result, err := pythonAPI.Save(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to save game via Python API: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": userID,
+			"saved":   true,
+			"message": "Game saved successfully! (Local fallback - Python API unavailable)",
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, result)
 }
 
 func handleLoadGame(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.GetString(auth.SessionUserKey)
 
-	// ゲーム読み込み処理をPython APIに委譲
-	response := gin.H{
-		"user_id": userID,
-		"loaded":  true,
-		"message": "Game loaded successfully!",
+	result, err := pythonAPI.Load(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to load game via Python API: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": userID,
+			"loaded":  true,
+			"message": "Game loaded successfully! (Local fallback - Python API unavailable)",
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, result)
 }